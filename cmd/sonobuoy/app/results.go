@@ -0,0 +1,137 @@
+/*
+Copyright the Sonobuoy contributors 2019
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/client/results"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/driver"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/driver/daemonset"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/driver/job"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/manifest"
+)
+
+const (
+	resultsFormatHuman = "human"
+	resultsFormatJUnit = "junit"
+
+	resultsPluginsDirName = "plugins"
+	resultsDirName        = "results"
+)
+
+type resultsInput struct {
+	pluginName string
+	dir        string
+	format     string
+}
+
+func NewCmdResults() *cobra.Command {
+	input := resultsInput{}
+
+	cmd := &cobra.Command{
+		Use:   "results <path-to-results-dir>",
+		Short: "Post-process and display results from a Sonobuoy run",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			input.dir = args[0]
+			return runResults(cmd.OutOrStdout(), input)
+		},
+	}
+
+	cmd.Flags().StringVar(&input.format, "format", resultsFormatHuman,
+		fmt.Sprintf("Output format: %q or %q", resultsFormatHuman, resultsFormatJUnit))
+	cmd.Flags().StringVar(&input.pluginName, "plugin", "",
+		"Only show results for the named plugin; defaults to every plugin found in the results dir")
+
+	return cmd
+}
+
+func runResults(out io.Writer, input resultsInput) error {
+	plugins, err := resolvePlugins(input.dir, input.pluginName)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range plugins {
+		item, errs := results.PostProcessPlugin(p, input.dir, 0)
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "error processing results for plugin %v: %v\n", p.GetName(), err)
+		}
+
+		switch input.format {
+		case resultsFormatJUnit:
+			if err := item.ToJUnitXML(out); err != nil {
+				return err
+			}
+		default:
+			fmt.Fprintf(out, "Plugin: %v\nStatus: %v\n", item.Name, item.Status)
+		}
+	}
+	return nil
+}
+
+// resolvePlugins figures out which plugins to post-process: just name, if
+// given, or every plugin directory found under dir/plugins otherwise.
+func resolvePlugins(dir, name string) ([]plugin.Interface, error) {
+	names := []string{name}
+	if name == "" {
+		entries, err := ioutil.ReadDir(filepath.Join(dir, resultsPluginsDirName))
+		if err != nil {
+			return nil, fmt.Errorf("discovering plugins in %v: %v", dir, err)
+		}
+		names = nil
+		for _, e := range entries {
+			if e.IsDir() {
+				names = append(names, e.Name())
+			}
+		}
+	}
+
+	plugins := make([]plugin.Interface, 0, len(names))
+	for _, n := range names {
+		plugins = append(plugins, newResultsPlugin(dir, n))
+	}
+	return plugins, nil
+}
+
+// newResultsPlugin builds the plugin.Interface PostProcessPlugin needs to
+// process name's results. The results dir has no record of the plugin's
+// original driver (job vs daemonset), so it's inferred from shape: a
+// daemonset plugin's results are nested one level deeper, under a directory
+// per node.
+func newResultsPlugin(dir, name string) plugin.Interface {
+	base := driver.Base{Definition: manifest.Manifest{
+		SonobuoyConfig: manifest.SonobuoyConfig{PluginName: name},
+	}}
+
+	resultsDir := filepath.Join(dir, resultsPluginsDirName, name, resultsDirName)
+	entries, _ := ioutil.ReadDir(resultsDir)
+	for _, e := range entries {
+		if e.IsDir() {
+			return &daemonset.Plugin{Base: base}
+		}
+	}
+	return &job.Plugin{Base: base}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright the Sonobuoy contributors 2019
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/driver/daemonset"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/driver/job"
+)
+
+func writeResultsFile(t *testing.T, dir, name, contents string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+		t.Fatalf("failed to create dir for %v: %v", name, err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write %v: %v", name, err)
+	}
+}
+
+func TestResolvePlugins(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sonobuoy-results-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeResultsFile(t, dir, "plugins/e2e/results/output.xml", "<testsuite></testsuite>")
+	writeResultsFile(t, dir, "plugins/systemd-logs/results/node-a/output.xml", "<testsuite></testsuite>")
+
+	t.Run("named plugin is used as-is", func(t *testing.T) {
+		plugins, err := resolvePlugins(dir, "e2e")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(plugins) != 1 || plugins[0].GetName() != "e2e" {
+			t.Fatalf("expected just plugin %q, got %v", "e2e", plugins)
+		}
+		if _, ok := plugins[0].(*job.Plugin); !ok {
+			t.Errorf("expected a job.Plugin for a flat results dir, got %T", plugins[0])
+		}
+	})
+
+	t.Run("no name discovers every plugin in the results dir", func(t *testing.T) {
+		plugins, err := resolvePlugins(dir, "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(plugins) != 2 {
+			t.Fatalf("expected 2 plugins, got %v", plugins)
+		}
+	})
+
+	t.Run("a plugin with per-node subdirectories is treated as a daemonset", func(t *testing.T) {
+		plugins, err := resolvePlugins(dir, "systemd-logs")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(plugins) != 1 {
+			t.Fatalf("expected just plugin %q, got %v", "systemd-logs", plugins)
+		}
+		if _, ok := plugins[0].(*daemonset.Plugin); !ok {
+			t.Errorf("expected a daemonset.Plugin for a per-node results dir, got %T", plugins[0])
+		}
+	})
+}
+
+func TestRunResults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sonobuoy-results-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeResultsFile(t, dir, "plugins/e2e/results/output.xml",
+		`<testsuite><testcase name="passes"></testcase></testsuite>`)
+
+	var buf bytes.Buffer
+	if err := runResults(&buf, resultsInput{dir: dir, format: resultsFormatHuman}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := buf.String(); !strings.Contains(got, "Plugin: e2e") || !strings.Contains(got, "Status: passed") {
+		t.Errorf("unexpected output:\n%s", got)
+	}
+}
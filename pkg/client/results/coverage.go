@@ -0,0 +1,306 @@
+/*
+Copyright the Sonobuoy contributors 2019
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const coverageDirName = "coverage"
+
+// fileCoverage is the covered/total line count for a single source file,
+// however the underlying format expressed it (Go cover "statements", LCOV
+// "lines", or Cobertura "lines").
+type fileCoverage struct {
+	File         string
+	CoveredLines int
+	TotalLines   int
+}
+
+func (c fileCoverage) percentage() float64 {
+	if c.TotalLines == 0 {
+		return 0
+	}
+	return 100 * float64(c.CoveredLines) / float64(c.TotalLines)
+}
+
+// parseCoverageFile dispatches to a coverage-format parser based on the
+// file's extension: Cobertura is XML, LCOV is ".info", and a Go cover
+// profile is anything else (conventionally ".out").
+func parseCoverageFile(path string) ([]fileCoverage, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xml":
+		return parseCoberturaProfile(path)
+	case ".info":
+		return parseLCOVProfile(path)
+	default:
+		return parseGoCoverProfile(path)
+	}
+}
+
+// parseGoCoverProfile parses the output of `go test -coverprofile`: a
+// "mode: <mode>" header followed by lines of the form
+// "<file>:<startline>.<startcol>,<endline>.<endcol> <numstmt> <count>".
+// Each line contributes numstmt statements to its file, covered if count>0.
+func parseGoCoverProfile(path string) ([]fileCoverage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	order := []string{}
+	totals := map[string]*fileCoverage{}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			continue
+		}
+		file := line[:colon]
+		fields := strings.Fields(line[colon+1:])
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("malformed cover profile line: %q", line)
+		}
+		numStmt, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("malformed statement count in %q: %v", line, err)
+		}
+		count, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("malformed hit count in %q: %v", line, err)
+		}
+
+		fc, ok := totals[file]
+		if !ok {
+			fc = &fileCoverage{File: file}
+			totals[file] = fc
+			order = append(order, file)
+		}
+		fc.TotalLines += numStmt
+		if count > 0 {
+			fc.CoveredLines += numStmt
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]fileCoverage, 0, len(order))
+	for _, file := range order {
+		out = append(out, *totals[file])
+	}
+	return out, nil
+}
+
+// parseLCOVProfile parses an LCOV .info file: SF:<path> opens a record,
+// DA:<line>,<hits> reports one line's hit count, and end_of_record closes
+// it.
+func parseLCOVProfile(path string) ([]fileCoverage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []fileCoverage
+	var cur *fileCoverage
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "SF:"):
+			cur = &fileCoverage{File: strings.TrimPrefix(line, "SF:")}
+		case strings.HasPrefix(line, "DA:"):
+			if cur == nil {
+				continue
+			}
+			parts := strings.Split(strings.TrimPrefix(line, "DA:"), ",")
+			if len(parts) != 2 {
+				continue
+			}
+			cur.TotalLines++
+			if hits, err := strconv.Atoi(parts[1]); err == nil && hits > 0 {
+				cur.CoveredLines++
+			}
+		case line == "end_of_record":
+			if cur != nil {
+				out = append(out, *cur)
+				cur = nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+type coberturaCoverage struct {
+	XMLName  xml.Name           `xml:"coverage"`
+	Packages []coberturaPackage `xml:"packages>package"`
+}
+
+type coberturaPackage struct {
+	Classes []coberturaClass `xml:"classes>class"`
+}
+
+type coberturaClass struct {
+	Filename string          `xml:"filename,attr"`
+	Lines    []coberturaLine `xml:"lines>line"`
+}
+
+type coberturaLine struct {
+	Hits int `xml:"hits,attr"`
+}
+
+// parseCoberturaProfile parses a Cobertura XML coverage report, summing each
+// <class>'s per-line hit counts into a fileCoverage.
+func parseCoberturaProfile(path string) ([]fileCoverage, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc coberturaCoverage
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var out []fileCoverage
+	for _, pkg := range doc.Packages {
+		for _, cls := range pkg.Classes {
+			fc := fileCoverage{File: cls.Filename}
+			for _, l := range cls.Lines {
+				fc.TotalLines++
+				if l.Hits > 0 {
+					fc.CoveredLines++
+				}
+			}
+			out = append(out, fc)
+		}
+	}
+	return out, nil
+}
+
+// coverageItemStatus reports StatusFailed when threshold checking is
+// enabled (threshold > 0) and pct falls short of it; otherwise coverage
+// alone never fails a plugin.
+func coverageItemStatus(pct, threshold float64) string {
+	if threshold > 0 && pct < threshold {
+		return StatusFailed
+	}
+	return StatusPassed
+}
+
+// buildCoverageItems turns a flat list of per-file coverages into the
+// "coverage" group Item that PostProcessPlugin appends to a plugin's root
+// Item (see appendCoverage): one child
+// Item per file plus an aggregate percentage on the group itself. Threshold
+// checking is an aggregate-level concern only - per-file Items always report
+// StatusPassed so that one low-coverage file can't fail the whole plugin
+// when the overall, weighted coverage still clears the threshold.
+func buildCoverageItems(covs []fileCoverage, threshold float64) Item {
+	group := Item{Name: "coverage"}
+
+	var totalCovered, totalLines int
+	for _, c := range covs {
+		pct := c.percentage()
+		group.Items = append(group.Items, Item{
+			Name:   c.File,
+			Status: StatusPassed,
+			Details: map[string]interface{}{
+				"coverage": pct,
+			},
+		})
+		totalCovered += c.CoveredLines
+		totalLines += c.TotalLines
+	}
+
+	overall := fileCoverage{CoveredLines: totalCovered, TotalLines: totalLines}.percentage()
+	group.Status = coverageItemStatus(overall, threshold)
+	group.Details = map[string]interface{}{"coverage": overall}
+	return group
+}
+
+// collectCoverageFiles reads every file directly inside dir (skipping
+// subdirectories) and parses each with parseCoverageFile, returning the
+// combined per-file coverage. A missing dir is not an error: most plugins
+// don't report coverage at all.
+func collectCoverageFiles(dir string) ([]fileCoverage, []error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, []error{err}
+	}
+
+	var out []fileCoverage
+	var errs []error
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		covs, err := parseCoverageFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("parsing coverage file %v: %v", e.Name(), err))
+			continue
+		}
+		out = append(out, covs...)
+	}
+	return out, errs
+}
+
+// mergeFileCoverage combines coverage observed for the same file across
+// multiple sources (e.g. one daemonset plugin's nodes) by summing covered
+// and total line counts, preserving first-seen file order.
+func mergeFileCoverage(covs []fileCoverage) []fileCoverage {
+	order := []string{}
+	totals := map[string]*fileCoverage{}
+	for _, c := range covs {
+		fc, ok := totals[c.File]
+		if !ok {
+			cp := c
+			fc = &cp
+			fc.CoveredLines, fc.TotalLines = 0, 0
+			totals[c.File] = fc
+			order = append(order, c.File)
+		}
+		fc.CoveredLines += c.CoveredLines
+		fc.TotalLines += c.TotalLines
+	}
+
+	out := make([]fileCoverage, 0, len(order))
+	for _, file := range order {
+		out = append(out, *totals[file])
+	}
+	return out
+}
@@ -0,0 +1,165 @@
+/*
+Copyright the Sonobuoy contributors 2019
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// eventRecord is a single newline-delimited JSON test event, modeled after
+// Deno's test runner protocol. `Result` is left as json.RawMessage since it
+// is either the bare string "ok"/"ignored" or an object like
+// {"failed":"msg"}.
+type eventRecord struct {
+	Kind string `json:"kind"`
+
+	// "plan"
+	Pending  int `json:"pending"`
+	Filtered int `json:"filtered"`
+
+	// "wait"/"result"/"step"
+	Name   string `json:"name"`
+	Parent string `json:"parent"`
+
+	// "result"/"step"
+	Duration int             `json:"duration"`
+	Result   json.RawMessage `json:"result"`
+}
+
+// parseEventsFile parses a newline-delimited JSON event stream (ResultFormat
+// "events") into an Item tree. Each top-level testcase becomes an Item under
+// the returned root; steps reported for a testcase become child Items under
+// it. Malformed lines don't abort processing: they're recorded as an error
+// Item so callers can see partial results from a plugin that is still
+// running or crashed mid-stream.
+func parseEventsFile(path string) (Item, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Item{}, err
+	}
+	defer f.Close()
+
+	root := Item{Name: filepath.Base(path)}
+	order := []string{}
+	byName := map[string]*Item{}
+
+	getCase := func(name string) *Item {
+		if item, ok := byName[name]; ok {
+			return item
+		}
+		item := &Item{Name: name, Status: StatusUnknown}
+		byName[name] = item
+		order = append(order, name)
+		return item
+	}
+
+	scanner := bufio.NewScanner(f)
+	// Event lines can carry sizeable diagnostic output; grow the buffer well
+	// past bufio's 64KiB default line limit.
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var ev eventRecord
+		if err := json.Unmarshal(line, &ev); err != nil {
+			root.Items = append(root.Items, Item{
+				Name:   fmt.Sprintf("malformed event (line %d)", lineNum),
+				Status: StatusUnknown,
+				Details: map[string]interface{}{
+					"error": err.Error(),
+					"line":  string(line),
+				},
+			})
+			continue
+		}
+
+		switch ev.Kind {
+		case "plan":
+			root.Details = map[string]interface{}{
+				"pending":  ev.Pending,
+				"filtered": ev.Filtered,
+			}
+		case "wait":
+			getCase(ev.Name)
+		case "result":
+			applyEventResult(getCase(ev.Name), ev)
+		case "step":
+			parent := getCase(ev.Parent)
+			step := Item{Name: ev.Name}
+			applyEventResult(&step, ev)
+			parent.Items = append(parent.Items, step)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Item{}, err
+	}
+
+	for _, name := range order {
+		root.Items = append(root.Items, *byName[name])
+	}
+	root.Status = aggregateStatus(root.Items...)
+	return root, nil
+}
+
+// applyEventResult fills in item's status/details from a "result" or "step"
+// event's Result field, which is either the bare string "ok"/"ignored" or an
+// object of the form {"failed":"msg"}.
+func applyEventResult(item *Item, ev eventRecord) {
+	if ev.Duration > 0 {
+		if item.Details == nil {
+			item.Details = map[string]interface{}{}
+		}
+		item.Details["duration"] = ev.Duration
+	}
+
+	var asString string
+	if json.Unmarshal(ev.Result, &asString) == nil {
+		switch asString {
+		case "ok":
+			item.Status = StatusPassed
+		case "ignored":
+			item.Status = StatusSkipped
+		default:
+			item.Status = StatusUnknown
+		}
+		return
+	}
+
+	var asFailure struct {
+		Failed string `json:"failed"`
+	}
+	if err := json.Unmarshal(ev.Result, &asFailure); err == nil && asFailure.Failed != "" {
+		item.Status = StatusFailed
+		if item.Details == nil {
+			item.Details = map[string]interface{}{}
+		}
+		item.Details["failure"] = asFailure.Failed
+		return
+	}
+
+	item.Status = StatusUnknown
+}
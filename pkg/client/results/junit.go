@@ -0,0 +1,127 @@
+/*
+Copyright the Sonobuoy contributors 2019
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// junitTestSuites is the root element of a JUnit XML document. Some
+// producers omit the wrapping <testsuites> and emit a bare <testsuite>;
+// both are handled by parseJUnitFile.
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Error     *junitMessage `xml:"error"`
+	Skipped   *junitMessage `xml:"skipped"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// parseJUnitFile reads a JUnit XML file and converts it into an Item tree:
+// the file becomes the root, each <testsuite> an Item, and each <testcase>
+// a leaf Item underneath it.
+func parseJUnitFile(path string) (Item, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Item{}, err
+	}
+
+	var doc junitTestSuites
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		// Fall back to a bare <testsuite> document.
+		var suite junitTestSuite
+		if err2 := xml.Unmarshal(data, &suite); err2 != nil {
+			return Item{}, err
+		}
+		doc.TestSuites = []junitTestSuite{suite}
+	}
+
+	root := Item{Name: filepath.Base(path)}
+	for _, suite := range doc.TestSuites {
+		root.Items = append(root.Items, junitSuiteToItem(suite))
+	}
+	root.Status = aggregateStatus(root.Items...)
+	return root, nil
+}
+
+func junitSuiteToItem(suite junitTestSuite) Item {
+	item := Item{Name: suite.Name}
+	for _, tc := range suite.TestCases {
+		item.Items = append(item.Items, junitCaseToItem(tc))
+	}
+	item.Status = aggregateStatus(item.Items...)
+	return item
+}
+
+func junitCaseToItem(tc junitTestCase) Item {
+	item := Item{
+		Name:    tc.Name,
+		Status:  StatusPassed,
+		Details: map[string]interface{}{},
+	}
+	if tc.ClassName != "" {
+		// Kept alongside, not folded into Name: two parameterized/table
+		// tests can legitimately share a short Name across different
+		// classes in the same suite, and flaky-retry grouping (see
+		// mergeFlakyRetries/mergeFlakyAcrossFiles) needs to tell them apart.
+		item.Metadata = map[string]string{"classname": tc.ClassName}
+	}
+	if tc.Time != "" {
+		item.Details["time"] = tc.Time
+	}
+	switch {
+	case tc.Failure != nil:
+		item.Status = StatusFailed
+		item.Details["failure"] = firstNonEmpty(tc.Failure.Message, tc.Failure.Body)
+	case tc.Error != nil:
+		item.Status = StatusUnknown
+		item.Details["error"] = firstNonEmpty(tc.Error.Message, tc.Error.Body)
+	case tc.Skipped != nil:
+		item.Status = StatusSkipped
+	}
+	if len(item.Details) == 0 {
+		item.Details = nil
+	}
+	return item
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
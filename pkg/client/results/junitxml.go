@@ -0,0 +1,199 @@
+/*
+Copyright the Sonobuoy contributors 2019
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// junitXMLTestSuites/junitXMLTestSuite/junitXMLTestCase mirror the subset of
+// the JUnit XML schema that CI systems actually consume. They're kept
+// separate from the junitTestSuites/junitTestSuite/junitTestCase types used
+// to parse incoming JUnit results since the two directions serialize
+// different fields (e.g. computed counts here vs. attrs read there).
+type junitXMLTestSuites struct {
+	XMLName xml.Name            `xml:"testsuites"`
+	Name    string              `xml:"name,attr"`
+	Suites  []junitXMLTestSuite `xml:"testsuite"`
+}
+
+type junitXMLTestSuite struct {
+	Name     string             `xml:"name,attr"`
+	Tests    int                `xml:"tests,attr"`
+	Failures int                `xml:"failures,attr"`
+	Errors   int                `xml:"errors,attr"`
+	Skipped  int                `xml:"skipped,attr"`
+	Time     string             `xml:"time,attr"`
+	Cases    []junitXMLTestCase `xml:"testcase"`
+}
+
+type junitXMLTestCase struct {
+	ClassName string           `xml:"classname,attr"`
+	Name      string           `xml:"name,attr"`
+	Time      string           `xml:"time,attr"`
+	Failure   *junitXMLMessage `xml:"failure,omitempty"`
+	Error     *junitXMLMessage `xml:"error,omitempty"`
+	Skipped   *junitXMLMessage `xml:"skipped,omitempty"`
+
+	// FlakyFailures records one entry per failing attempt of a StatusFlaky
+	// Item, using the same <flakyFailure> element Maven Surefire emits for
+	// retried tests. The testcase itself carries no <failure>, since a
+	// flaky test is, on the whole, considered passing.
+	FlakyFailures []junitXMLMessage `xml:"flakyFailure"`
+}
+
+type junitXMLMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// ToJUnitXML serializes a post-processed Item tree back into JUnit XML so
+// that CI systems which natively understand JUnit (but not Sonobuoy's own
+// Item format) can consume plugin results directly. i is expected to be a
+// plugin's root Item, as returned by PostProcessPlugin: each of its direct
+// children (per-node for daemonsets, one logical group for jobs) becomes a
+// <testsuite>, and every leaf Item underneath becomes a <testcase>. Deeper
+// nesting (e.g. t.Run-style subtests) is flattened into the testcase name
+// using a "/" separator, since JUnit has no native concept of nested tests.
+func (i Item) ToJUnitXML(w io.Writer) error {
+	out := junitXMLTestSuites{Name: i.Name}
+	for _, group := range i.Items {
+		out.Suites = append(out.Suites, buildJUnitSuite(group))
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return fmt.Errorf("encoding junit xml: %v", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func buildJUnitSuite(group Item) junitXMLTestSuite {
+	suite := junitXMLTestSuite{Name: group.Name}
+	if len(group.Items) == 0 {
+		// group has no nested testcases of its own - e.g. a raw-format
+		// result file, or the errors/timeout sentinel Item - so group itself
+		// is the one outcome to report; render it as a single testcase
+		// rather than emitting an empty, misleadingly-passing suite.
+		suite.Cases = append(suite.Cases, flattenJUnitCases(group.Name, group.Name, group)...)
+	} else {
+		for _, child := range group.Items {
+			suite.Cases = append(suite.Cases, flattenJUnitCases(group.Name, child.Name, child)...)
+		}
+	}
+
+	var totalSeconds float64
+	for _, c := range suite.Cases {
+		suite.Tests++
+		switch {
+		case c.Failure != nil:
+			suite.Failures++
+		case c.Error != nil:
+			suite.Errors++
+		case c.Skipped != nil:
+			suite.Skipped++
+		}
+		if secs, err := strconv.ParseFloat(c.Time, 64); err == nil {
+			totalSeconds += secs
+		}
+	}
+	suite.Time = strconv.FormatFloat(totalSeconds, 'f', 3, 64)
+	return suite
+}
+
+// flattenJUnitCases walks item, collapsing any non-leaf descendants into a
+// single "/"-joined testcase name so every leaf becomes exactly one
+// <testcase classname="<suite name>" name="<path from suite child to leaf>">.
+func flattenJUnitCases(classname, name string, item Item) []junitXMLTestCase {
+	if item.Status == StatusFlaky {
+		tc := junitXMLTestCase{
+			ClassName: classname,
+			Name:      name,
+			Time:      itemDurationSeconds(item),
+		}
+		for _, attempt := range item.Items {
+			if isFailureStatus(attempt.Status) {
+				tc.FlakyFailures = append(tc.FlakyFailures, junitXMLMessage{Message: detailString(attempt, "failure")})
+			}
+		}
+		return []junitXMLTestCase{tc}
+	}
+
+	if len(item.Items) == 0 {
+		tc := junitXMLTestCase{
+			ClassName: classname,
+			Name:      name,
+			Time:      itemDurationSeconds(item),
+		}
+		switch item.Status {
+		case StatusFailed:
+			tc.Failure = &junitXMLMessage{Message: detailString(item, "failure")}
+		case StatusUnknown, StatusTimeout:
+			tc.Error = &junitXMLMessage{Message: detailString(item, "error")}
+		case StatusSkipped:
+			tc.Skipped = &junitXMLMessage{}
+		}
+		return []junitXMLTestCase{tc}
+	}
+
+	var out []junitXMLTestCase
+	for _, sub := range item.Items {
+		out = append(out, flattenJUnitCases(classname, name+"/"+sub.Name, sub)...)
+	}
+	return out
+}
+
+func detailString(item Item, key string) string {
+	if item.Details == nil {
+		return ""
+	}
+	if s, ok := item.Details[key].(string); ok {
+		return s
+	}
+	return ""
+}
+
+// itemDurationSeconds returns the item's recorded duration, formatted the
+// way JUnit expects the testcase "time" attribute: seconds, as a decimal.
+// Parsers in this package record it either as a string in Details["time"]
+// (seconds from JUnit input, or "<n>ms" from a TAP "# time=" directive) or
+// as milliseconds in Details["duration"] (from the events format).
+func itemDurationSeconds(item Item) string {
+	if item.Details == nil {
+		return "0"
+	}
+	if s, ok := item.Details["time"].(string); ok {
+		if strings.HasSuffix(s, "ms") {
+			if ms, err := strconv.ParseFloat(strings.TrimSuffix(s, "ms"), 64); err == nil {
+				return strconv.FormatFloat(ms/1000.0, 'f', 3, 64)
+			}
+		}
+		return s
+	}
+	if ms, ok := item.Details["duration"].(int); ok {
+		return strconv.FormatFloat(float64(ms)/1000.0, 'f', 3, 64)
+	}
+	return "0"
+}
@@ -0,0 +1,107 @@
+/*
+Copyright the Sonobuoy contributors 2019
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestToJUnitXML(t *testing.T) {
+	root := Item{
+		Name: "e2e",
+		Items: []Item{
+			{
+				Name: "output.xml",
+				Items: []Item{
+					{Name: "passes", Status: StatusPassed, Details: map[string]interface{}{"time": "1.5"}},
+					{
+						Name:   "has subtests",
+						Status: StatusFailed,
+						Items: []Item{
+							{Name: "sub1", Status: StatusPassed},
+							{Name: "sub2", Status: StatusFailed, Details: map[string]interface{}{"failure": "boom"}},
+						},
+					},
+					{Name: "was skipped", Status: StatusSkipped},
+					{
+						Name:   "flaky test",
+						Status: StatusFlaky,
+						Items: []Item{
+							{Name: "flaky test", Status: StatusFailed, Details: map[string]interface{}{"failure": "timed out"}},
+							{Name: "flaky test", Status: StatusPassed},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := root.ToJUnitXML(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		`<testsuite name="output.xml" tests="5" failures="1" errors="0" skipped="1"`,
+		`<testcase classname="output.xml" name="passes" time="1.5">`,
+		`<testcase classname="output.xml" name="has subtests/sub1"`,
+		`<testcase classname="output.xml" name="has subtests/sub2"`,
+		`<failure message="boom">`,
+		`<testcase classname="output.xml" name="was skipped"`,
+		`<skipped`,
+		`<testcase classname="output.xml" name="flaky test" time="0">`,
+		`<flakyFailure message="timed out">`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestToJUnitXMLChildlessGroup(t *testing.T) {
+	// A raw-format result file and the errors/timeout sentinel both come out
+	// of PostProcessPlugin as childless leaf Items directly under the root,
+	// not suite Items with nested testcases.
+	root := Item{
+		Name: "e2e",
+		Items: []Item{
+			{Name: "output.log", Status: StatusPassed, Details: map[string]interface{}{"output": "ok"}},
+			{Name: "timeout", Status: StatusTimeout, Details: map[string]interface{}{"error": "plugin did not complete within 5m0s"}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := root.ToJUnitXML(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		`<testsuite name="output.log" tests="1" failures="0" errors="0" skipped="0"`,
+		`<testcase classname="output.log" name="output.log"`,
+		`<testsuite name="timeout" tests="1" failures="0" errors="1" skipped="0"`,
+		`<testcase classname="timeout" name="timeout"`,
+		`<error message="plugin did not complete within 5m0s">`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
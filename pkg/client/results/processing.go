@@ -0,0 +1,575 @@
+/*
+Copyright the Sonobuoy contributors 2019
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package results provides helpers for turning the raw output a plugin
+// leaves behind in its results tarball into the nested Item tree that the
+// rest of Sonobuoy (CLI output, aggregation, status reporting) understands.
+package results
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/driver/daemonset"
+	"github.com/vmware-tanzu/sonobuoy/pkg/plugin/driver/job"
+)
+
+// Status values an Item can take on. Non-leaf items have their status
+// computed from their children via aggregateStatus.
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusUnknown = "unknown"
+	StatusTimeout = "timeout"
+	StatusSkipped = "skipped"
+	// StatusFlaky marks a test that was retried and produced both a passing
+	// and a failing attempt; see mergeFlakyRetries.
+	StatusFlaky = "flaky"
+
+	resultsDirName = "results"
+	errorsDirName  = "errors"
+)
+
+// Item is a result, as represented in a Sonobuoy plugin's results. It is the
+// core data type for the results package and used to determine the status
+// (success/fail/etc) of a given plugin as well as individual tests.
+type Item struct {
+	Name     string                 `json:"name"`
+	Status   string                 `json:"status"`
+	Metadata map[string]string      `json:"metadata,omitempty"`
+	Details  map[string]interface{} `json:"details,omitempty"`
+	Items    []Item                 `json:"items,omitempty"`
+
+	// Counts summarizes the leaf outcomes underneath this Item, including
+	// errors/timeout sentinel Items and the per-file Items of a "coverage"
+	// group. It is only populated on the root Item returned by
+	// PostProcessPlugin, not on every node of the tree.
+	Counts *Counts `json:"counts,omitempty"`
+}
+
+// Counts is a summary of leaf test outcomes, broken down by status.
+type Counts struct {
+	Total   int `json:"total"`
+	Passed  int `json:"passed"`
+	Failed  int `json:"failed"`
+	Skipped int `json:"skipped"`
+	Flaky   int `json:"flaky"`
+}
+
+// countLeaves recursively tallies the leaf Items (those with no children) in
+// items by status.
+func countLeaves(items []Item) Counts {
+	var c Counts
+	for _, it := range items {
+		if it.Status == StatusFlaky {
+			// A flaky Item's children are its retry attempts, not separate
+			// tests, so it counts as a single flaky leaf.
+			c.Total++
+			c.Flaky++
+			continue
+		}
+		if len(it.Items) > 0 {
+			child := countLeaves(it.Items)
+			c.Total += child.Total
+			c.Passed += child.Passed
+			c.Failed += child.Failed
+			c.Skipped += child.Skipped
+			c.Flaky += child.Flaky
+			continue
+		}
+
+		c.Total++
+		switch it.Status {
+		case StatusSkipped:
+			c.Skipped++
+		case StatusFailed, StatusUnknown, StatusTimeout:
+			c.Failed++
+		default:
+			c.Passed++
+		}
+	}
+	return c
+}
+
+// PostProcessPlugin walks the on-disk results for the given plugin (rooted
+// at dir, Sonobuoy's aggregated results directory) and returns the Item tree
+// representing its outcome along with any errors encountered while
+// processing individual result files. A non-empty Item is still returned
+// even when errors occur so that callers can surface partial results.
+//
+// coverageThreshold is the minimum coverage percentage a plugin reporting
+// coverage must meet; 0 (the default) disables threshold checking entirely,
+// so coverage is reported but never fails a plugin on its own.
+func PostProcessPlugin(p plugin.Interface, dir string, coverageThreshold float64) (Item, []error) {
+	name := p.GetName()
+	pluginDir := filepath.Join(dir, "plugins", name)
+
+	switch p.(type) {
+	case *job.Plugin:
+		return postProcessJob(p, pluginDir, coverageThreshold)
+	case *daemonset.Plugin:
+		return postProcessDaemonSet(p, pluginDir, coverageThreshold)
+	default:
+		return Item{Name: name, Status: StatusUnknown}, []error{
+			fmt.Errorf("unsupported plugin driver %T for results post-processing", p),
+		}
+	}
+}
+
+func postProcessJob(p plugin.Interface, pluginDir string, coverageThreshold float64) (Item, []error) {
+	root := Item{Name: p.GetName()}
+	var errs []error
+
+	items, itemErrs := processResultsDir(p, filepath.Join(pluginDir, resultsDirName))
+	errs = append(errs, itemErrs...)
+	root.Items = append(root.Items, mergeFlakyAcrossFiles(mergeFlakyRetries(items))...)
+
+	if errItem, ok := processErrorsDir(filepath.Join(pluginDir, errorsDirName)); ok {
+		root.Items = append(root.Items, errItem)
+	}
+
+	root.Status = aggregateStatus(root.Items...)
+
+	covs, covErrs := collectCoverageFiles(filepath.Join(pluginDir, coverageDirName))
+	errs = append(errs, covErrs...)
+	if len(covs) > 0 {
+		appendCoverage(&root, buildCoverageItems(covs, coverageThreshold), len(root.Items) > 0)
+	}
+
+	counts := countLeaves(root.Items)
+	root.Counts = &counts
+	return root, errs
+}
+
+func postProcessDaemonSet(p plugin.Interface, pluginDir string, coverageThreshold float64) (Item, []error) {
+	root := Item{Name: p.GetName()}
+	var errs []error
+
+	nodes := map[string]*Item{}
+	nodeOrder := []string{}
+	getNode := func(name string) *Item {
+		if n, ok := nodes[name]; ok {
+			return n
+		}
+		n := &Item{Name: name}
+		nodes[name] = n
+		nodeOrder = append(nodeOrder, name)
+		return n
+	}
+
+	resultsRoot := filepath.Join(pluginDir, resultsDirName)
+	nodeDirs, _ := ioutil.ReadDir(resultsRoot)
+	for _, nodeDir := range nodeDirs {
+		if !nodeDir.IsDir() {
+			continue
+		}
+		node := getNode(nodeDir.Name())
+		items, itemErrs := processResultsDir(p, filepath.Join(resultsRoot, nodeDir.Name()))
+		errs = append(errs, itemErrs...)
+		node.Items = append(node.Items, mergeFlakyAcrossFiles(mergeFlakyRetries(items))...)
+	}
+
+	errorsRoot := filepath.Join(pluginDir, errorsDirName)
+	nodeErrDirs, _ := ioutil.ReadDir(errorsRoot)
+	for _, nodeDir := range nodeErrDirs {
+		if !nodeDir.IsDir() {
+			continue
+		}
+		if errItem, ok := processErrorsDir(filepath.Join(errorsRoot, nodeDir.Name())); ok {
+			node := getNode(nodeDir.Name())
+			node.Items = append(node.Items, errItem)
+		}
+	}
+
+	sort.Strings(nodeOrder)
+	for _, name := range nodeOrder {
+		root.Items = append(root.Items, *nodes[name])
+	}
+
+	root.Status = aggregateStatus(root.Items...)
+
+	// Coverage isn't reported per-node the way test results are: the same
+	// source tree is (partially) exercised on every node, so the per-node
+	// profiles are merged into a single plugin-wide coverage summary rather
+	// than nested under each node.
+	coverageRoot := filepath.Join(pluginDir, coverageDirName)
+	nodeCovDirs, _ := ioutil.ReadDir(coverageRoot)
+	var covs []fileCoverage
+	for _, nodeDir := range nodeCovDirs {
+		if !nodeDir.IsDir() {
+			continue
+		}
+		nodeCovs, covErrs := collectCoverageFiles(filepath.Join(coverageRoot, nodeDir.Name()))
+		errs = append(errs, covErrs...)
+		covs = append(covs, nodeCovs...)
+	}
+	if len(covs) > 0 {
+		appendCoverage(&root, buildCoverageItems(mergeFileCoverage(covs), coverageThreshold), len(root.Items) > 0)
+	}
+
+	counts := countLeaves(root.Items)
+	root.Counts = &counts
+	return root, errs
+}
+
+// processResultsDir parses every result file found in dir (optionally
+// filtered to p.GetResultFiles()) according to p's ResultFormat and returns
+// the Items produced.
+func processResultsDir(p plugin.Interface, dir string) ([]Item, []error) {
+	files, err := resultFilesIn(dir, p.GetResultFiles())
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	var items []Item
+	var errs []error
+	for _, f := range files {
+		item, err := parseResultFile(p.GetResultFormat(), f)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("processing %v: %v", f, err))
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, errs
+}
+
+// resultFilesIn lists the files directly inside dir, optionally restricted
+// to the given basenames (an empty/nil list means "take them all").
+func resultFilesIn(dir string, only []string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	wanted := map[string]bool{}
+	for _, name := range only {
+		wanted[name] = true
+	}
+
+	var out []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if len(wanted) > 0 && !wanted[e.Name()] {
+			continue
+		}
+		out = append(out, filepath.Join(dir, e.Name()))
+	}
+	return out, nil
+}
+
+// processErrorsDir looks for a sentinel "error" file dropped by the
+// aggregator when it could not collect results for a plugin/node (e.g. the
+// pod never ran, or timed out). Returns ok=false when there was nothing to
+// report.
+func processErrorsDir(dir string) (Item, bool) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil || len(entries) == 0 {
+		return Item{}, false
+	}
+
+	// Only one error file is expected per plugin/node; if there happen to
+	// be more we just report the first so callers still get a status.
+	entry := entries[0]
+	data, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+	status := StatusUnknown
+	if entry.Name() == "timeout" {
+		status = StatusTimeout
+	}
+
+	item := Item{
+		Name:   entry.Name(),
+		Status: status,
+		Details: map[string]interface{}{
+			"error": string(data),
+		},
+	}
+	if err != nil {
+		item.Details["error"] = err.Error()
+	}
+	return item, true
+}
+
+// parseResultFile dispatches to the parser registered for format, parsing
+// the file at path into an Item.
+func parseResultFile(format, path string) (Item, error) {
+	switch format {
+	case "junit", "":
+		return parseJUnitFile(path)
+	case "raw":
+		return parseRawFile(path)
+	case "events":
+		return parseEventsFile(path)
+	case "tap":
+		return parseTAPFile(path)
+	default:
+		return Item{}, fmt.Errorf("unknown result format %q", format)
+	}
+}
+
+func parseRawFile(path string) (Item, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Item{}, err
+	}
+	return Item{
+		Name:   filepath.Base(path),
+		Status: StatusPassed,
+		Details: map[string]interface{}{
+			"output": string(data),
+		},
+	}, nil
+}
+
+// aggregateStatus computes the overall status of a group of sibling Items,
+// mutating each Item's own Status in the process: non-leaf items have their
+// Status set to the aggregate of their children, and leaf items with no
+// Status are set to StatusUnknown. The one exception is an Item already
+// marked StatusFlaky: its children are its retry attempts, not a normal
+// group of sub-results, so its own status is left alone. Priority, highest
+// first: failed > unknown > flaky > passed > skipped (a skipped-only group
+// aggregates to StatusSkipped; any other/unrecognized status is treated as
+// passing).
+func aggregateStatus(items ...Item) string {
+	if len(items) == 0 {
+		return StatusUnknown
+	}
+
+	var sawFailure, sawUnknown, sawFlaky, sawPassed, sawSkipped bool
+	for i := range items {
+		switch {
+		case items[i].Status == StatusFlaky:
+			// Leave as-is; still normalize the retry attempts underneath.
+			aggregateStatus(items[i].Items...)
+		case len(items[i].Items) > 0:
+			items[i].Status = aggregateStatus(items[i].Items...)
+		case items[i].Status == "":
+			items[i].Status = StatusUnknown
+		}
+
+		switch {
+		case isFailureStatus(items[i].Status):
+			sawFailure = true
+		case items[i].Status == StatusUnknown:
+			sawUnknown = true
+		case items[i].Status == StatusFlaky:
+			sawFlaky = true
+		case items[i].Status == StatusSkipped:
+			sawSkipped = true
+		default:
+			sawPassed = true
+		}
+	}
+
+	switch {
+	case sawFailure:
+		return StatusFailed
+	case sawUnknown:
+		return StatusUnknown
+	case sawFlaky:
+		return StatusFlaky
+	case sawPassed:
+		return StatusPassed
+	case sawSkipped:
+		return StatusSkipped
+	default:
+		return StatusPassed
+	}
+}
+
+func isFailureStatus(status string) bool {
+	return status == StatusFailed || status == StatusTimeout
+}
+
+// appendCoverage appends the "coverage" group built by buildCoverageItems to
+// root.Items and folds its status into root.Status. The coverage group's own
+// Status is authoritative - set directly from the configured threshold
+// rather than derived from its always-passing per-file children - so it's
+// folded in here instead of being run back through aggregateStatus, which
+// would otherwise recompute (and likely clobber) it. hadOtherItems is
+// whether root.Items held anything before coverage was appended: with
+// nothing else to report on, the plugin's status is simply the coverage
+// group's.
+func appendCoverage(root *Item, coverage Item, hadOtherItems bool) {
+	root.Details = map[string]interface{}{"coverage": coverage.Details["coverage"]}
+	root.Items = append(root.Items, coverage)
+	switch {
+	case !hadOtherItems:
+		root.Status = coverage.Status
+	case isFailureStatus(coverage.Status):
+		root.Status = StatusFailed
+	}
+}
+
+// mergeFlakyRetries collapses sibling Items that share the same Name and
+// classname (e.g. a testcase rerun in place within the same result file)
+// into a single Item. A test whose attempts disagree - at least one pass and
+// at least one failure - is considered flaky: the attempts are preserved as
+// its Items so the history isn't lost, but its own Status becomes
+// StatusFlaky. A test whose attempts all agree is left as its most recent
+// attempt. Merging the same test reported across separate result files is
+// handled afterwards by mergeFlakyAcrossFiles, since those attempts never
+// end up as siblings.
+func mergeFlakyRetries(items []Item) []Item {
+	order := make([]string, 0, len(items))
+	groups := map[string][]Item{}
+	for _, it := range items {
+		it.Items = mergeFlakyRetries(it.Items)
+		key := flakyMergeKey(it)
+		if _, seen := groups[key]; !seen {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], it)
+	}
+
+	out := make([]Item, 0, len(order))
+	for _, key := range order {
+		attempts := groups[key]
+		if len(attempts) == 1 {
+			out = append(out, attempts[0])
+			continue
+		}
+		out = append(out, collapseFlakyAttempts(attempts[0].Name, attempts))
+	}
+	return out
+}
+
+// flakyMergeKey identifies "the same test" for retry-merging purposes: its
+// Name, scoped by classname when the result format reports one (JUnit
+// does; the flatter formats don't, and leave it empty). Keying on Name alone
+// would wrongly treat two distinct parameterized/table tests that happen to
+// share a short Name across different classes as retries of one another.
+func flakyMergeKey(it Item) string {
+	return it.Metadata["classname"] + "\x00" + it.Name
+}
+
+func collapseFlakyAttempts(name string, attempts []Item) Item {
+	var hasPass, hasFail bool
+	for _, a := range attempts {
+		switch {
+		case a.Status == StatusPassed:
+			hasPass = true
+		case isFailureStatus(a.Status):
+			hasFail = true
+		}
+	}
+	if hasPass && hasFail {
+		return Item{Name: name, Status: StatusFlaky, Metadata: attempts[0].Metadata, Items: attempts}
+	}
+	// Attempts agree; nothing flaky to report, so just keep the latest one.
+	return attempts[len(attempts)-1]
+}
+
+// mergeFlakyAcrossFiles looks for the same test - identified by its full
+// name path from directly under a file Item down to the leaf (e.g.
+// "suite/testcase" for JUnit or just "testcase" for the flatter formats),
+// scoped by classname when the leaf reports one - reported by more than one
+// of fileItems. When its attempts disagree, the same pass+fail rule as
+// collapseFlakyAttempts applies: the first-seen occurrence becomes a single
+// flaky Item preserving every attempt as its Items, and the other
+// occurrence(s) are pruned from their file's tree so the test is only
+// reported once. A file left with nothing of its own after pruning is
+// dropped entirely.
+func mergeFlakyAcrossFiles(fileItems []Item) []Item {
+	items := make([]Item, len(fileItems))
+	copy(items, fileItems)
+
+	order := []string{}
+	attempts := map[string][]*Item{}
+	var collect func(path string, item *Item)
+	collect = func(path string, item *Item) {
+		if len(item.Items) == 0 {
+			key := path + "\x00" + item.Metadata["classname"]
+			if _, seen := attempts[key]; !seen {
+				order = append(order, key)
+			}
+			attempts[key] = append(attempts[key], item)
+			return
+		}
+		for i := range item.Items {
+			collect(path+"/"+item.Items[i].Name, &item.Items[i])
+		}
+	}
+	for i := range items {
+		for j := range items[i].Items {
+			collect(items[i].Items[j].Name, &items[i].Items[j])
+		}
+	}
+
+	for _, path := range order {
+		tcs := attempts[path]
+		if len(tcs) < 2 {
+			continue
+		}
+		var hasPass, hasFail bool
+		for _, tc := range tcs {
+			switch {
+			case tc.Status == StatusPassed:
+				hasPass = true
+			case isFailureStatus(tc.Status):
+				hasFail = true
+			}
+		}
+		if !hasPass || !hasFail {
+			continue
+		}
+
+		retries := make([]Item, len(tcs))
+		for i, tc := range tcs {
+			retries[i] = *tc
+		}
+		*tcs[0] = Item{Name: tcs[0].Name, Status: StatusFlaky, Metadata: tcs[0].Metadata, Items: retries}
+		// Mark the remaining occurrences for removal by pruneMergedAway;
+		// a real test result never has an empty name.
+		for _, tc := range tcs[1:] {
+			tc.Name = ""
+		}
+	}
+
+	wrapper := Item{Items: items}
+	pruneMergedAway(&wrapper)
+	return wrapper.Items
+}
+
+// pruneMergedAway removes the Items mergeFlakyAcrossFiles marked for
+// deletion, along with any branch that consequently ends up with none of
+// its own children left.
+func pruneMergedAway(item *Item) {
+	if len(item.Items) == 0 {
+		return
+	}
+	kept := item.Items[:0]
+	for _, child := range item.Items {
+		if child.Name == "" {
+			continue
+		}
+		wasBranch := len(child.Items) > 0
+		pruneMergedAway(&child)
+		if wasBranch && len(child.Items) == 0 {
+			continue
+		}
+		kept = append(kept, child)
+	}
+	item.Items = kept
+}
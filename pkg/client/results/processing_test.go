@@ -78,6 +78,10 @@ func TestPostProcessPlugin(t *testing.T) {
 		plugin       plugin.Interface
 		expectedErrs []string
 
+		// coverageThreshold is passed straight through to PostProcessPlugin;
+		// 0 (the default) disables threshold checking.
+		coverageThreshold float64
+
 		// key is used to lookup both the directory and the expected results.
 		key string
 	}{
@@ -153,11 +157,48 @@ func TestPostProcessPlugin(t *testing.T) {
 			desc:   "Timeout errors cause timeout status",
 			key:    "job-timeout",
 			plugin: getPlugin("job-timeout", "job", "junit", []string{}),
+		}, {
+			desc:   "Job events stream with plan/result/step in order",
+			key:    "job-events-ordered",
+			plugin: getPlugin("job-events-ordered", "job", "events", []string{}),
+		}, {
+			desc:   "Job events stream with a step arriving before its testcase's wait",
+			key:    "job-events-outoforder",
+			plugin: getPlugin("job-events-outoforder", "job", "events", []string{}),
+		}, {
+			desc:   "Job events stream with a malformed line surfaced as an error Item",
+			key:    "job-events-malformed",
+			plugin: getPlugin("job-events-malformed", "job", "events", []string{}),
+		}, {
+			desc:   "Job TAP stream with a diagnostic block, a skip directive, and a subtest",
+			key:    "job-tap-01",
+			plugin: getPlugin("job-tap-01", "job", "tap", []string{}),
+		}, {
+			desc:   "Daemonset with one node emitting TAP and another erroring out",
+			key:    "ds-tap-errors",
+			plugin: getPlugin("ds-tap-errors", "daemonset", "tap", []string{}),
+		}, {
+			desc:   "Job reports coverage from a Go cover profile",
+			key:    "job-cover-01",
+			plugin: getPlugin("job-cover-01", "job", "", []string{}),
+		}, {
+			desc:   "Daemonset reports coverage from an LCOV file merged across nodes",
+			key:    "ds-lcov-01",
+			plugin: getPlugin("ds-lcov-01", "daemonset", "", []string{}),
+		}, {
+			desc:              "Job coverage below a configured threshold fails",
+			key:               "job-cover-02",
+			plugin:            getPlugin("job-cover-02", "job", "", []string{}),
+			coverageThreshold: 60,
+		}, {
+			desc:   "Job testcase failing in one file and passing in another merges into a flaky Item",
+			key:    "job-flaky-01",
+			plugin: getPlugin("job-flaky-01", "job", "junit", []string{}),
 		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.desc, func(t *testing.T) {
-			item, errs := PostProcessPlugin(tc.plugin, mockDataDir(tc.key))
+			item, errs := PostProcessPlugin(tc.plugin, mockDataDir(tc.key), tc.coverageThreshold)
 			if len(errs) > 0 {
 				for _, e := range errs {
 					t.Errorf("Unexpected error: %v", e)
@@ -512,6 +553,53 @@ func TestAggregateStatus(t *testing.T) {
 				},
 			},
 			expected: StatusFailed,
+		}, {
+			desc: "Flaky test alongside a passing test still surfaces as flaky overall",
+			input: []Item{
+				{
+					Name:   "flakyTest",
+					Status: StatusFlaky,
+					Items: []Item{
+						{Name: "attempt1", Status: StatusFailed},
+						{Name: "attempt2", Status: StatusPassed},
+					},
+				},
+				{Name: "otherTest", Status: StatusPassed},
+			},
+			expectedItems: []Item{
+				{
+					Name:   "flakyTest",
+					Status: StatusFlaky,
+					Items: []Item{
+						{Name: "attempt1", Status: StatusFailed},
+						{Name: "attempt2", Status: StatusPassed},
+					},
+				},
+				{Name: "otherTest", Status: StatusPassed},
+			},
+			expected: StatusFlaky,
+		}, {
+			desc: "All skipped group aggregates to skipped",
+			input: []Item{
+				{Status: StatusSkipped},
+				{Status: StatusSkipped},
+			},
+			expectedItems: []Item{
+				{Status: StatusSkipped},
+				{Status: StatusSkipped},
+			},
+			expected: StatusSkipped,
+		}, {
+			desc: "Mixed skip and fail still fails",
+			input: []Item{
+				{Status: StatusSkipped},
+				{Status: StatusFailed},
+			},
+			expectedItems: []Item{
+				{Status: StatusSkipped},
+				{Status: StatusFailed},
+			},
+			expected: StatusFailed,
 		},
 	}
 
@@ -528,3 +616,189 @@ func TestAggregateStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestMergeFlakyRetries(t *testing.T) {
+	tcs := []struct {
+		desc     string
+		input    []Item
+		expected []Item
+	}{
+		{
+			desc:     "No siblings share a name, nothing to merge",
+			input:    []Item{{Name: "testA", Status: StatusPassed}, {Name: "testB", Status: StatusFailed}},
+			expected: []Item{{Name: "testA", Status: StatusPassed}, {Name: "testB", Status: StatusFailed}},
+		}, {
+			desc:     "Repeated testcase that always fails is left as-is",
+			input:    []Item{{Name: "testA", Status: StatusFailed}, {Name: "testA", Status: StatusFailed}},
+			expected: []Item{{Name: "testA", Status: StatusFailed}},
+		}, {
+			desc: "Repeated testcase with a pass and a fail becomes flaky",
+			input: []Item{
+				{Name: "testA", Status: StatusFailed},
+				{Name: "testA", Status: StatusPassed},
+			},
+			expected: []Item{
+				{
+					Name:   "testA",
+					Status: StatusFlaky,
+					Items: []Item{
+						{Name: "testA", Status: StatusFailed},
+						{Name: "testA", Status: StatusPassed},
+					},
+				},
+			},
+		}, {
+			desc: "Merging recurses into nested Items",
+			input: []Item{
+				{
+					Name: "suite",
+					Items: []Item{
+						{Name: "testA", Status: StatusFailed},
+						{Name: "testA", Status: StatusPassed},
+					},
+				},
+			},
+			expected: []Item{
+				{
+					Name: "suite",
+					Items: []Item{
+						{
+							Name:   "testA",
+							Status: StatusFlaky,
+							Items: []Item{
+								{Name: "testA", Status: StatusFailed},
+								{Name: "testA", Status: StatusPassed},
+							},
+						},
+					},
+				},
+			},
+		}, {
+			desc: "Same name but different classname is not flaky, even with a pass and a fail",
+			input: []Item{
+				{Name: "testA", Status: StatusFailed, Metadata: map[string]string{"classname": "pkg.ClassOne"}},
+				{Name: "testA", Status: StatusPassed, Metadata: map[string]string{"classname": "pkg.ClassTwo"}},
+			},
+			expected: []Item{
+				{Name: "testA", Status: StatusFailed, Metadata: map[string]string{"classname": "pkg.ClassOne"}},
+				{Name: "testA", Status: StatusPassed, Metadata: map[string]string{"classname": "pkg.ClassTwo"}},
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			out := mergeFlakyRetries(tc.input)
+			if diff := pretty.Compare(tc.expected, out); diff != "" {
+				t.Errorf("\n\n%s\n", diff)
+			}
+		})
+	}
+}
+
+func TestMergeFlakyAcrossFiles(t *testing.T) {
+	tcs := []struct {
+		desc     string
+		input    []Item
+		expected []Item
+	}{
+		{
+			desc: "Distinct files with no overlapping tests are untouched",
+			input: []Item{
+				{Name: "output.xml", Items: []Item{{Name: "suite", Items: []Item{{Name: "testA", Status: StatusPassed}}}}},
+				{Name: "output2.xml", Items: []Item{{Name: "suite", Items: []Item{{Name: "testB", Status: StatusFailed}}}}},
+			},
+			expected: []Item{
+				{Name: "output.xml", Items: []Item{{Name: "suite", Items: []Item{{Name: "testA", Status: StatusPassed}}}}},
+				{Name: "output2.xml", Items: []Item{{Name: "suite", Items: []Item{{Name: "testB", Status: StatusFailed}}}}},
+			},
+		}, {
+			desc: "Same test failing in every file is not flaky",
+			input: []Item{
+				{Name: "output.xml", Items: []Item{{Name: "suite", Items: []Item{{Name: "flaky test", Status: StatusFailed}}}}},
+				{Name: "output2.xml", Items: []Item{{Name: "suite", Items: []Item{{Name: "flaky test", Status: StatusFailed}}}}},
+			},
+			expected: []Item{
+				{Name: "output.xml", Items: []Item{{Name: "suite", Items: []Item{{Name: "flaky test", Status: StatusFailed}}}}},
+				{Name: "output2.xml", Items: []Item{{Name: "suite", Items: []Item{{Name: "flaky test", Status: StatusFailed}}}}},
+			},
+		}, {
+			desc: "Same test failing in one file and passing in another merges into a single flaky Item",
+			input: []Item{
+				{Name: "output.xml", Items: []Item{{Name: "suite", Items: []Item{{Name: "flaky test", Status: StatusFailed}}}}},
+				{Name: "output2.xml", Items: []Item{{Name: "suite", Items: []Item{{Name: "flaky test", Status: StatusPassed}}}}},
+			},
+			expected: []Item{
+				{
+					Name: "output.xml",
+					Items: []Item{
+						{
+							Name: "suite",
+							Items: []Item{
+								{
+									Name:   "flaky test",
+									Status: StatusFlaky,
+									Items: []Item{
+										{Name: "flaky test", Status: StatusFailed},
+										{Name: "flaky test", Status: StatusPassed},
+									},
+								},
+							},
+						},
+					},
+				},
+				// output2.xml had nothing left of its own once its attempt
+				// was folded into output.xml's flaky Item, so it's dropped.
+			},
+		}, {
+			desc: "A file untouched by merging survives alongside a merged one",
+			input: []Item{
+				{Name: "output.xml", Items: []Item{{Name: "suite", Items: []Item{
+					{Name: "flaky test", Status: StatusFailed},
+					{Name: "stable test", Status: StatusPassed},
+				}}}},
+				{Name: "output2.xml", Items: []Item{{Name: "suite", Items: []Item{{Name: "flaky test", Status: StatusPassed}}}}},
+			},
+			expected: []Item{
+				{Name: "output.xml", Items: []Item{{Name: "suite", Items: []Item{
+					{
+						Name:   "flaky test",
+						Status: StatusFlaky,
+						Items: []Item{
+							{Name: "flaky test", Status: StatusFailed},
+							{Name: "flaky test", Status: StatusPassed},
+						},
+					},
+					{Name: "stable test", Status: StatusPassed},
+				}}}},
+			},
+		}, {
+			desc: "Same name but different classname across files is not flaky",
+			input: []Item{
+				{Name: "output.xml", Items: []Item{{Name: "suite", Items: []Item{
+					{Name: "testA", Status: StatusFailed, Metadata: map[string]string{"classname": "pkg.ClassOne"}},
+				}}}},
+				{Name: "output2.xml", Items: []Item{{Name: "suite", Items: []Item{
+					{Name: "testA", Status: StatusPassed, Metadata: map[string]string{"classname": "pkg.ClassTwo"}},
+				}}}},
+			},
+			expected: []Item{
+				{Name: "output.xml", Items: []Item{{Name: "suite", Items: []Item{
+					{Name: "testA", Status: StatusFailed, Metadata: map[string]string{"classname": "pkg.ClassOne"}},
+				}}}},
+				{Name: "output2.xml", Items: []Item{{Name: "suite", Items: []Item{
+					{Name: "testA", Status: StatusPassed, Metadata: map[string]string{"classname": "pkg.ClassTwo"}},
+				}}}},
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			out := mergeFlakyAcrossFiles(tc.input)
+			if diff := pretty.Compare(tc.expected, out); diff != "" {
+				t.Errorf("\n\n%s\n", diff)
+			}
+		})
+	}
+}
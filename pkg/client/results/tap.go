@@ -0,0 +1,241 @@
+/*
+Copyright the Sonobuoy contributors 2019
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// tapRecord is a single "ok"/"not ok" line read from a TAP stream, along
+// with the indentation it was read at (used to reconstruct subtest nesting)
+// and its parsed Item.
+type tapRecord struct {
+	indent int
+	item   Item
+}
+
+// parseTAPFile parses a TAP (Test Anything Protocol) v13 stream into the
+// Item tree used by PostProcessPlugin. The `1..N` plan line is consumed but
+// not represented structurally; `ok`/`not ok` lines become Items; `# SKIP`,
+// `# TODO`, and `# time=...ms` directives are recorded on those Items;
+// indented `ok`/`not ok` blocks become child Items of the preceding testcase
+// (TAP subtests); YAML-ish diagnostic blocks between `---`/`...` attach to
+// the immediately preceding testcase's Details; and a `Bail out!` line fails
+// the whole plugin, recording the reason under Details["bail"].
+func parseTAPFile(path string) (Item, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Item{}, err
+	}
+
+	root := Item{Name: filepath.Base(path)}
+	var records []tapRecord
+	var bailReason string
+
+	inDiag := false
+	var diagLines []string
+
+	closeDiag := func() {
+		if len(records) > 0 {
+			applyTAPDiagnostics(&records[len(records)-1].item, diagLines)
+		}
+		diagLines = nil
+		inDiag = false
+	}
+
+	for _, raw := range strings.Split(string(data), "\n") {
+		line := strings.TrimRight(raw, "\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+
+		if inDiag {
+			if trimmed == "..." {
+				closeDiag()
+			} else {
+				diagLines = append(diagLines, trimmed)
+			}
+			continue
+		}
+
+		switch {
+		case trimmed == "---":
+			inDiag = true
+			diagLines = nil
+		case trimmed == "TAP version 13":
+			// Informational only.
+		case isTAPPlanLine(trimmed):
+			// Informational only; the Item tree's own shape tells us what ran.
+		case strings.HasPrefix(trimmed, "Bail out!"):
+			bailReason = strings.TrimSpace(strings.TrimPrefix(trimmed, "Bail out!"))
+		case strings.HasPrefix(trimmed, "ok") || strings.HasPrefix(trimmed, "not ok"):
+			records = append(records, tapRecord{indent: indent, item: parseTAPResultLine(trimmed)})
+		default:
+			// Comment or other diagnostic output outside a YAML block; ignored.
+		}
+	}
+
+	root.Items = buildTAPForest(records)
+	root.Status = aggregateStatus(root.Items...)
+
+	if bailReason != "" {
+		// A failed leaf keeps the bail-out from being lost when the plugin's
+		// status is later re-aggregated from this Item's children.
+		root.Items = append(root.Items, Item{
+			Name:    "Bail out!",
+			Status:  StatusFailed,
+			Details: map[string]interface{}{"bail": bailReason},
+		})
+		root.Status = StatusFailed
+		root.Details = map[string]interface{}{"bail": bailReason}
+	}
+
+	return root, nil
+}
+
+func isTAPPlanLine(s string) bool {
+	idx := strings.Index(s, "..")
+	if idx < 0 {
+		return false
+	}
+	before, after := s[:idx], s[idx+2:]
+	fields := strings.Fields(after)
+	return isAllDigits(before) && len(fields) > 0 && isAllDigits(fields[0])
+}
+
+func isAllDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// buildTAPForest reconstructs the nested Item tree implied by records'
+// indentation: a block of records more deeply indented than the record
+// preceding it becomes that record's children (TAP subtests).
+func buildTAPForest(records []tapRecord) []Item {
+	if len(records) == 0 {
+		return nil
+	}
+
+	minIndent := records[0].indent
+	var out []Item
+	for i := 0; i < len(records); {
+		if records[i].indent > minIndent {
+			// Malformed stream (deeper indent with no preceding parent at this
+			// level); attach to the last item we emitted instead of dropping it.
+			if len(out) > 0 {
+				out[len(out)-1].Items = append(out[len(out)-1].Items, buildTAPForest(records[i:i+1])...)
+			}
+			i++
+			continue
+		}
+
+		j := i + 1
+		for j < len(records) && records[j].indent > minIndent {
+			j++
+		}
+
+		item := records[i].item
+		item.Items = buildTAPForest(records[i+1 : j])
+		if len(item.Items) > 0 {
+			item.Status = aggregateStatus(item.Items...)
+		}
+		out = append(out, item)
+		i = j
+	}
+	return out
+}
+
+// parseTAPResultLine parses a single "ok"/"not ok" line (without any leading
+// whitespace) into an Item.
+func parseTAPResultLine(line string) Item {
+	notOK := strings.HasPrefix(line, "not ok")
+	rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(line, "not ok"), "ok"))
+
+	// Optional test number.
+	rest = strings.TrimLeft(rest, "0123456789")
+	rest = strings.TrimSpace(rest)
+	rest = strings.TrimPrefix(rest, "-")
+	rest = strings.TrimSpace(rest)
+
+	desc, directive := rest, ""
+	if idx := strings.Index(rest, "#"); idx >= 0 {
+		desc = strings.TrimSpace(rest[:idx])
+		directive = strings.TrimSpace(rest[idx+1:])
+	}
+
+	item := Item{Name: desc, Status: StatusPassed}
+	if notOK {
+		item.Status = StatusFailed
+	}
+
+	if directive == "" {
+		return item
+	}
+
+	details := map[string]interface{}{}
+	lowered := strings.ToLower(directive)
+	switch {
+	case strings.HasPrefix(lowered, "skip"):
+		item.Status = StatusSkipped
+		if reason := strings.TrimSpace(directive[len("SKIP"):]); reason != "" {
+			details["skip"] = reason
+		}
+	case strings.HasPrefix(lowered, "todo"):
+		details["todo"] = strings.TrimSpace(directive[len("TODO"):])
+	case strings.HasPrefix(lowered, "time="):
+		details["time"] = strings.TrimPrefix(directive, "time=")
+	default:
+		details["directive"] = directive
+	}
+	item.Details = details
+	return item
+}
+
+// applyTAPDiagnostics parses a YAML-ish diagnostic block (simple "key:
+// value" lines, as written by most TAP producers) and merges it into item's
+// Details.
+func applyTAPDiagnostics(item *Item, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	if item.Details == nil {
+		item.Details = map[string]interface{}{}
+	}
+	for _, line := range lines {
+		idx := strings.Index(line, ":")
+		if idx < 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:idx])
+		value := strings.Trim(strings.TrimSpace(line[idx+1:]), `"`)
+		if key == "" {
+			continue
+		}
+		item.Details[key] = value
+	}
+}